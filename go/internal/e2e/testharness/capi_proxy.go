@@ -0,0 +1,352 @@
+package testharness
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProxyMode controls how CapiProxy resolves requests it receives from the CLI.
+type ProxyMode string
+
+const (
+	// ModeReplay serves responses exclusively from the recorded snapshot and
+	// fails the request when no matching exchange is cached. This is the
+	// default mode for CI and local test runs.
+	ModeReplay ProxyMode = "replay"
+
+	// ModeRecord forwards requests to the real Copilot API, relays the
+	// response back to the CLI unmodified, and appends the exchange to the
+	// snapshot file so it can be replayed later. The response is read in
+	// full before any of it is written back; it is not streamed
+	// incrementally.
+	ModeRecord ProxyMode = "record"
+
+	// ModePassthrough forwards requests to the real Copilot API without ever
+	// reading or writing a snapshot.
+	ModePassthrough ProxyMode = "passthrough"
+)
+
+// liveCapiURL is the real Copilot API endpoint that record and passthrough
+// modes forward requests to.
+const liveCapiURL = "https://api.githubcopilot.com"
+
+// modeFromEnv reads COPILOT_TEST_MODE, defaulting to ModeReplay when unset
+// or set to an unrecognized value.
+func modeFromEnv() ProxyMode {
+	switch ProxyMode(os.Getenv("COPILOT_TEST_MODE")) {
+	case ModeRecord:
+		return ModeRecord
+	case ModePassthrough:
+		return ModePassthrough
+	default:
+		return ModeReplay
+	}
+}
+
+// ParsedHttpExchange is a single captured request/response pair exchanged
+// between the CLI and the (real or replayed) Copilot API.
+type ParsedHttpExchange struct {
+	Method       string
+	Path         string
+	Headers      http.Header
+	RequestBody  []byte
+	StatusCode   int
+	ResponseBody []byte
+}
+
+// yamlExchange is the on-disk shape of a ParsedHttpExchange. Bodies are
+// stored as plain strings (JSON-pretty-printed where possible) rather than
+// the raw []byte fields on ParsedHttpExchange, which gopkg.in/yaml.v3 would
+// otherwise marshal as one YAML sequence element per byte.
+type yamlExchange struct {
+	Method       string      `yaml:"method"`
+	Path         string      `yaml:"path"`
+	Headers      http.Header `yaml:"headers"`
+	RequestBody  string      `yaml:"requestBody"`
+	StatusCode   int         `yaml:"statusCode"`
+	ResponseBody string      `yaml:"responseBody"`
+}
+
+// MarshalYAML renders bodies as pretty-printed JSON text so snapshots are
+// readable in a diff instead of a wall of per-byte integers.
+func (e ParsedHttpExchange) MarshalYAML() (interface{}, error) {
+	return yamlExchange{
+		Method:       e.Method,
+		Path:         e.Path,
+		Headers:      e.Headers,
+		RequestBody:  prettyJSON(e.RequestBody),
+		StatusCode:   e.StatusCode,
+		ResponseBody: prettyJSON(e.ResponseBody),
+	}, nil
+}
+
+// UnmarshalYAML reads back the yamlExchange text form written by MarshalYAML.
+func (e *ParsedHttpExchange) UnmarshalYAML(value *yaml.Node) error {
+	var y yamlExchange
+	if err := value.Decode(&y); err != nil {
+		return err
+	}
+	e.Method = y.Method
+	e.Path = y.Path
+	e.Headers = y.Headers
+	e.RequestBody = []byte(y.RequestBody)
+	e.StatusCode = y.StatusCode
+	e.ResponseBody = []byte(y.ResponseBody)
+	return nil
+}
+
+// CapiProxy is an HTTP proxy that sits between the Copilot CLI and
+// api.githubcopilot.com, serving recorded snapshots in replay mode and
+// capturing exchanges for inspection by tests.
+type CapiProxy struct {
+	mode ProxyMode
+
+	mu              sync.Mutex
+	reporter        testing.TB
+	snapshotPath    string
+	snapshot        []ParsedHttpExchange
+	exchanges       []ParsedHttpExchange
+	replayIndex     int
+	customRedactors []Redactor
+	redactors       []Redactor
+	stubs           []*StubRule
+
+	server *httptest.Server
+}
+
+// AddRedactor registers a redactor that runs, after the built-in ones, on
+// every exchange before it's written to or compared against a snapshot.
+func (p *CapiProxy) AddRedactor(r Redactor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.customRedactors = append(p.customRedactors, r)
+}
+
+// NewCapiProxy creates a proxy in the mode selected by COPILOT_TEST_MODE.
+func NewCapiProxy() *CapiProxy {
+	return &CapiProxy{mode: modeFromEnv()}
+}
+
+// SetMode overrides the proxy's mode, ignoring COPILOT_TEST_MODE. Intended
+// for tests that need to re-record a single snapshot in isolation.
+func (p *CapiProxy) SetMode(mode ProxyMode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mode = mode
+}
+
+// Start begins listening for CLI requests and returns the URL to configure
+// as COPILOT_API_URL.
+func (p *CapiProxy) Start() (string, error) {
+	p.server = httptest.NewServer(http.HandlerFunc(p.handle))
+	return p.server.URL, nil
+}
+
+// Configure points the proxy at the snapshot file for the current subtest.
+// In replay mode the snapshot is loaded so recorded exchanges can be served
+// without hitting the network, and a missing snapshot is an error. Record
+// mode starts from an empty snapshot and rewrites the file with the
+// exchanges it forwards; passthrough mode never reads or writes it. t is
+// used to report replay mismatches via t.Errorf so a diff shows up in the
+// test's own output.
+func (p *CapiProxy) Configure(t testing.TB, snapshotPath, workDir, homeDir string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reporter = t
+	p.snapshotPath = snapshotPath
+	p.exchanges = nil
+	p.snapshot = nil
+	p.replayIndex = 0
+	p.stubs = nil
+	p.redactors = append(defaultRedactors(workDir, homeDir), p.customRedactors...)
+
+	if p.mode != ModeReplay {
+		return nil
+	}
+
+	snapshot, err := loadSnapshot(snapshotPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no snapshot at %s (run with COPILOT_TEST_MODE=record to create one)", snapshotPath)
+		}
+		return err
+	}
+	p.snapshot = snapshot
+	return nil
+}
+
+// StopWithOptions shuts down the proxy's HTTP server.
+func (p *CapiProxy) StopWithOptions(testFailed bool) {
+	if p.server != nil {
+		p.server.Close()
+	}
+}
+
+// GetExchanges returns the exchanges captured since the last Configure call.
+func (p *CapiProxy) GetExchanges() ([]ParsedHttpExchange, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ParsedHttpExchange(nil), p.exchanges...), nil
+}
+
+func (p *CapiProxy) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	exchange := ParsedHttpExchange{
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		Headers:     r.Header,
+		RequestBody: body,
+	}
+
+	if rule := p.findStub(body); rule != nil {
+		p.serveStub(w, rule, &exchange)
+		p.mu.Lock()
+		p.exchanges = append(p.exchanges, exchange)
+		p.mu.Unlock()
+		return
+	}
+
+	p.mu.Lock()
+	mode := p.mode
+	p.mu.Unlock()
+
+	switch mode {
+	case ModeReplay:
+		p.replay(w, &exchange)
+	default:
+		p.forwardLive(w, r, body, &exchange)
+	}
+
+	p.mu.Lock()
+	p.exchanges = append(p.exchanges, exchange)
+	p.mu.Unlock()
+}
+
+// replay serves the exchange recorded at the current position in the
+// snapshot. Exchanges are consumed in recorded order, which is what lets a
+// mismatch be reported as a diff against "the request we expected next"
+// rather than an opaque cache miss.
+func (p *CapiProxy) replay(w http.ResponseWriter, exchange *ParsedHttpExchange) {
+	redacted := *exchange
+	redacted.RequestBody = append([]byte(nil), exchange.RequestBody...)
+
+	p.mu.Lock()
+	applyRedactors(&redacted, p.redactors)
+	idx := p.replayIndex
+	p.replayIndex++
+	var recorded *ParsedHttpExchange
+	if idx < len(p.snapshot) {
+		recorded = &p.snapshot[idx]
+	}
+	snapshotPath := p.snapshotPath
+	reporter := p.reporter
+	p.mu.Unlock()
+
+	if recorded != nil && exchangeMatches(*recorded, redacted) {
+		exchange.StatusCode = recorded.StatusCode
+		exchange.ResponseBody = recorded.ResponseBody
+		w.WriteHeader(recorded.StatusCode)
+		w.Write(recorded.ResponseBody)
+		return
+	}
+
+	msg := fmt.Sprintf("no matching cached response for request #%d (%s %s)\nsnapshot: %s\n%s",
+		idx, redacted.Method, redacted.Path, snapshotPath, diffExchanges(recorded, &redacted))
+	if reporter != nil {
+		reporter.Errorf("testharness: replay mismatch:\n%s", msg)
+	}
+	http.Error(w, msg, http.StatusNotFound)
+	exchange.StatusCode = http.StatusNotFound
+	exchange.ResponseBody = []byte(msg)
+}
+
+// exchangeMatches compares bodies via their pretty-printed form, since
+// recorded.RequestBody round-trips through that form on snapshot load (see
+// ParsedHttpExchange.MarshalYAML) while live.RequestBody is whatever the CLI
+// actually sent.
+func exchangeMatches(recorded, live ParsedHttpExchange) bool {
+	return recorded.Method == live.Method &&
+		recorded.Path == live.Path &&
+		prettyJSON(recorded.RequestBody) == prettyJSON(live.RequestBody)
+}
+
+func (p *CapiProxy) forwardLive(w http.ResponseWriter, r *http.Request, body []byte, exchange *ParsedHttpExchange) {
+	req, err := http.NewRequest(r.Method, liveCapiURL+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header = r.Header.Clone()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	exchange.StatusCode = resp.StatusCode
+	exchange.ResponseBody = respBody
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+
+	if p.mode == ModeRecord {
+		p.appendToSnapshot(*exchange)
+	}
+}
+
+func (p *CapiProxy) appendToSnapshot(exchange ParsedHttpExchange) {
+	p.mu.Lock()
+	applyRedactors(&exchange, p.redactors)
+	p.snapshot = append(p.snapshot, exchange)
+	snapshot := append([]ParsedHttpExchange(nil), p.snapshot...)
+	path := p.snapshotPath
+	p.mu.Unlock()
+
+	if err := saveSnapshot(path, snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "testharness: failed to write snapshot %s: %v\n", path, err)
+	}
+}
+
+func loadSnapshot(path string) ([]ParsedHttpExchange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var exchanges []ParsedHttpExchange
+	if err := yaml.Unmarshal(data, &exchanges); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s: %w", path, err)
+	}
+	return exchanges, nil
+}
+
+func saveSnapshot(path string, exchanges []ParsedHttpExchange) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(exchanges)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,56 @@
+package testharness
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParsedHttpExchangeYAMLRoundTrip(t *testing.T) {
+	original := []ParsedHttpExchange{{
+		Method:       "POST",
+		Path:         "/chat/completions",
+		RequestBody:  []byte(`{"messages":[{"role":"system"}]}`),
+		StatusCode:   200,
+		ResponseBody: []byte(`{"choices":[{"message":{"role":"assistant"}}]}`),
+	}}
+
+	data, err := yaml.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	if strings.Contains(string(data), "- 123") || strings.Count(string(data), "\n") > 40 {
+		t.Fatalf("expected a compact, readable snapshot, got a %d-line file:\n%s", strings.Count(string(data), "\n"), data)
+	}
+
+	var roundTripped []ParsedHttpExchange
+	if err := yaml.Unmarshal(data, &roundTripped); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if !exchangeMatches(roundTripped[0], original[0]) {
+		t.Fatalf("round-tripped exchange does not match original: %+v vs %+v", roundTripped[0], original[0])
+	}
+}
+
+func TestParsedHttpExchangeYAMLDoesNotHTMLEscapeBodies(t *testing.T) {
+	original := []ParsedHttpExchange{{
+		Method:      "POST",
+		Path:        "/chat/completions",
+		RequestBody: []byte(`{"prompt":"a < b && c > d"}`),
+	}}
+
+	data, err := yaml.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	if strings.Contains(string(data), `\u003c`) || strings.Contains(string(data), `\u0026`) {
+		t.Fatalf("expected the snapshot body to render literal <, >, & rather than HTML-escaped unicode sequences, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "a < b && c > d") {
+		t.Fatalf("expected the snapshot body to contain the literal prompt text, got:\n%s", data)
+	}
+}
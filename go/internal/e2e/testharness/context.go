@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	copilot "github.com/github/copilot-sdk/go"
+	"github.com/github/copilot-sdk/go/internal/e2e/testharness/expect"
 )
 
 var (
@@ -17,6 +18,28 @@ var (
 	cliPathOnce sync.Once
 )
 
+// sanitizeNonAlnum replaces everything but letters and digits, keeping
+// generated directory and file names safe on both Unix and Windows.
+var sanitizeNonAlnum = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// tempRoot returns the stable base directory scratch dirs are created
+// under: $TMPDIR/copilot-test if that's writable, else /tmp. Kept short and
+// flat (rather than nested under testing.T's own temp dir, which on macOS
+// already starts 50-90 chars deep under /var/folders/<hash>/T/) so there's
+// still room left for the Unix socket paths the CLI or its child processes
+// create underneath it.
+func tempRoot() string {
+	base := os.Getenv("TMPDIR")
+	if base == "" {
+		base = os.TempDir()
+	}
+	candidate := filepath.Join(base, "copilot-test")
+	if err := os.MkdirAll(candidate, 0o755); err == nil {
+		return candidate
+	}
+	return "/tmp"
+}
+
 // CLIPath returns the path to the Copilot CLI, discovering it once and caching.
 func CLIPath() string {
 	cliPathOnce.Do(func() {
@@ -55,32 +78,17 @@ func NewTestContext(t *testing.T) *TestContext {
 		t.Fatalf("CLI not found at %s. Run 'npm install' in the nodejs directory first.", cliPath)
 	}
 
-	homeDir, err := os.MkdirTemp("", "copilot-test-config-")
-	if err != nil {
-		t.Fatalf("Failed to create temp home dir: %v", err)
-	}
-
-	workDir, err := os.MkdirTemp("", "copilot-test-work-")
-	if err != nil {
-		os.RemoveAll(homeDir)
-		t.Fatalf("Failed to create temp work dir: %v", err)
-	}
+	ctx := &TestContext{CLIPath: cliPath}
+	ctx.HomeDir = ctx.TempDir(t, "config")
+	ctx.WorkDir = ctx.TempDir(t, "work")
 
 	proxy := NewCapiProxy()
 	proxyURL, err := proxy.Start()
 	if err != nil {
-		os.RemoveAll(homeDir)
-		os.RemoveAll(workDir)
 		t.Fatalf("Failed to start proxy: %v", err)
 	}
-
-	ctx := &TestContext{
-		CLIPath:  cliPath,
-		HomeDir:  homeDir,
-		WorkDir:  workDir,
-		ProxyURL: proxyURL,
-		proxy:    proxy,
-	}
+	ctx.ProxyURL = proxyURL
+	ctx.proxy = proxy
 
 	t.Cleanup(func() {
 		ctx.Close(t.Failed())
@@ -89,6 +97,26 @@ func NewTestContext(t *testing.T) *TestContext {
 	return ctx
 }
 
+// TempDir returns a fresh scratch directory named
+// "copilot-test-<TestName>-<suffix>-*", rooted directly under tempRoot().
+// Naming directories after the test that created them makes them easy to
+// spot when they're preserved for inspection after a failure.
+func (c *TestContext) TempDir(t *testing.T, suffix string) string {
+	t.Helper()
+
+	name := sanitizeNonAlnum.ReplaceAllString(t.Name(), "_")
+	dir, err := os.MkdirTemp(tempRoot(), "copilot-test-"+name+"-"+suffix+"-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() {
+		if !t.Failed() {
+			os.RemoveAll(dir)
+		}
+	})
+	return dir
+}
+
 // ConfigureForTest configures the proxy for a specific subtest.
 // Call this at the start of each t.Run subtest.
 func (c *TestContext) ConfigureForTest(t *testing.T) {
@@ -113,7 +141,7 @@ func (c *TestContext) ConfigureForTest(t *testing.T) {
 	if len(parts) < 2 {
 		t.Fatalf("Expected test name with subtest, got: %s", testName)
 	}
-	sanitizedName := strings.ToLower(regexp.MustCompile(`[^a-zA-Z0-9]`).ReplaceAllString(parts[1], "_"))
+	sanitizedName := strings.ToLower(sanitizeNonAlnum.ReplaceAllString(parts[1], "_"))
 	snapshotPath := filepath.Join("..", "..", "..", "test", "snapshots", testFile, sanitizedName+".yaml")
 
 	absSnapshotPath, err := filepath.Abs(snapshotPath)
@@ -121,22 +149,41 @@ func (c *TestContext) ConfigureForTest(t *testing.T) {
 		t.Fatalf("Failed to get absolute path: %v", err)
 	}
 
-	if err := c.proxy.Configure(absSnapshotPath, c.WorkDir); err != nil {
+	if err := c.proxy.Configure(t, absSnapshotPath, c.WorkDir, c.HomeDir); err != nil {
 		t.Fatalf("Failed to configure proxy: %v", err)
 	}
 }
 
-// Close cleans up the test context resources.
+// Stub begins scripting the proxy's responses programmatically instead of
+// loading them from a recorded snapshot. Stubs are consulted before the
+// snapshot cache and are cleared at the start of each subtest by
+// ConfigureForTest.
+func (c *TestContext) Stub() *Stub {
+	return &Stub{proxy: c.proxy}
+}
+
+// AddRedactor registers a redactor that runs on every exchange captured for
+// the remainder of the test, rewriting volatile fields to stable
+// placeholders before they're written to or compared against a snapshot.
+// Call before ConfigureForTest.
+func (c *TestContext) AddRedactor(r Redactor) {
+	c.proxy.AddRedactor(r)
+}
+
+// SetMode overrides the proxy's operating mode for the remainder of the
+// test, ignoring COPILOT_TEST_MODE. Call before ConfigureForTest so the new
+// mode is in effect when the snapshot is loaded.
+func (c *TestContext) SetMode(mode ProxyMode) {
+	c.proxy.SetMode(mode)
+}
+
+// Close cleans up the test context resources. HomeDir and WorkDir are
+// removed by the t.Cleanup registered in TempDir and don't need explicit
+// removal here.
 func (c *TestContext) Close(testFailed bool) {
 	if c.proxy != nil {
 		c.proxy.StopWithOptions(testFailed)
 	}
-	if c.HomeDir != "" {
-		os.RemoveAll(c.HomeDir)
-	}
-	if c.WorkDir != "" {
-		os.RemoveAll(c.WorkDir)
-	}
 }
 
 // GetExchanges retrieves the captured HTTP exchanges from the proxy.
@@ -144,6 +191,23 @@ func (c *TestContext) GetExchanges() ([]ParsedHttpExchange, error) {
 	return c.proxy.GetExchanges()
 }
 
+// Assert returns a fluent assertion chain over the exchanges captured so
+// far, e.g. ctx.Assert(t).RequestCount(1).NthRequest(0).ToolCall("edit_file").
+func (c *TestContext) Assert(t *testing.T) *expect.Exchanges {
+	t.Helper()
+
+	exchanges, err := c.GetExchanges()
+	if err != nil {
+		t.Fatalf("Failed to get exchanges: %v", err)
+	}
+
+	converted := make([]expect.Exchange, len(exchanges))
+	for i, e := range exchanges {
+		converted[i] = expect.Exchange(e)
+	}
+	return expect.On(t, converted)
+}
+
 // Env returns environment variables configured for isolated testing.
 func (c *TestContext) Env() []string {
 	env := os.Environ()
@@ -0,0 +1,16 @@
+package testharness
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTempDirIsRootedDirectlyUnderTempRoot(t *testing.T) {
+	ctx := &TestContext{}
+	dir := ctx.TempDir(t, "config")
+
+	root := tempRoot()
+	if filepath.Dir(dir) != root {
+		t.Fatalf("expected %q to be a direct child of tempRoot() %q, got parent %q", dir, root, filepath.Dir(dir))
+	}
+}
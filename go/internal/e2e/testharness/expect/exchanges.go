@@ -0,0 +1,155 @@
+// Package expect provides a fluent assertion layer over the HTTP exchanges
+// captured by testharness.CapiProxy, so E2E tests don't have to hand-walk
+// []testharness.ParsedHttpExchange and re-implement the same JSON-poking in
+// every test file.
+package expect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Exchange mirrors testharness.ParsedHttpExchange field-for-field. It exists
+// so this package doesn't need to import testharness, which in turn wants to
+// return *Exchanges from TestContext.Assert.
+type Exchange struct {
+	Method       string
+	Path         string
+	Headers      http.Header
+	RequestBody  []byte
+	StatusCode   int
+	ResponseBody []byte
+}
+
+// Exchanges is a fluent assertion chain over a set of captured exchanges.
+type Exchanges struct {
+	t         testing.TB
+	exchanges []Exchange
+}
+
+// On starts an assertion chain over exchanges, failing t via t.Fatalf when
+// an expectation doesn't hold.
+func On(t testing.TB, exchanges []Exchange) *Exchanges {
+	t.Helper()
+	return &Exchanges{t: t, exchanges: exchanges}
+}
+
+// RequestCount asserts that exactly n requests were captured.
+func (e *Exchanges) RequestCount(n int) *Exchanges {
+	e.t.Helper()
+	if len(e.exchanges) != n {
+		e.t.Fatalf("expected %d requests, got %d", n, len(e.exchanges))
+	}
+	return e
+}
+
+// NthRequest returns an assertion over the i-th captured request (0-indexed).
+func (e *Exchanges) NthRequest(i int) *ExchangeAssertion {
+	e.t.Helper()
+	if i < 0 || i >= len(e.exchanges) {
+		e.t.Fatalf("expected at least %d requests, got %d", i+1, len(e.exchanges))
+	}
+	return &ExchangeAssertion{t: e.t, exchange: e.exchanges[i]}
+}
+
+// ExchangeAssertion asserts properties of a single captured exchange.
+type ExchangeAssertion struct {
+	t        testing.TB
+	exchange Exchange
+}
+
+// PathMatches asserts that the request path matches the given regexp.
+func (a *ExchangeAssertion) PathMatches(pattern string) *ExchangeAssertion {
+	a.t.Helper()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		a.t.Fatalf("invalid path pattern %q: %v", pattern, err)
+	}
+	if !re.MatchString(a.exchange.Path) {
+		a.t.Fatalf("expected path matching %q, got %q", pattern, a.exchange.Path)
+	}
+	return a
+}
+
+// BodyJSON asserts that the dot-separated path into the request body's JSON
+// equals want. Numeric segments index into arrays, e.g. "messages.0.role".
+func (a *ExchangeAssertion) BodyJSON(path string, want interface{}) *ExchangeAssertion {
+	a.t.Helper()
+	var body interface{}
+	if err := json.Unmarshal(a.exchange.RequestBody, &body); err != nil {
+		a.t.Fatalf("request body is not valid JSON: %v", err)
+	}
+	got, err := lookupPath(body, path)
+	if err != nil {
+		a.t.Fatalf("body path %q: %v", path, err)
+	}
+	if !jsonEqual(got, want) {
+		a.t.Fatalf("body path %q: expected %v, got %v", path, want, got)
+	}
+	return a
+}
+
+// ToolCall locates a tool call named name in the response body and returns
+// an assertion over its arguments. Tool calls are something the model
+// responds with, not something the CLI sends, so this looks at
+// ResponseBody - decoding it as SSE frames first (how the stub and the real
+// Copilot API stream tool calls back) and falling back to plain JSON.
+func (a *ExchangeAssertion) ToolCall(name string) *ToolCallAssertion {
+	a.t.Helper()
+
+	var body interface{}
+	if events, err := decodeSSE(a.exchange.ResponseBody); err == nil && len(events) > 0 {
+		body = events
+	} else if err := json.Unmarshal(a.exchange.ResponseBody, &body); err != nil {
+		a.t.Fatalf("response body is not valid JSON or SSE: %v", err)
+	}
+
+	args, ok := findToolCallArgs(body, name)
+	if !ok {
+		a.t.Fatalf("no tool call named %q found in response body", name)
+	}
+	return &ToolCallAssertion{t: a.t, name: name, args: args}
+}
+
+// jsonEqual compares got (already decoded from JSON) against want by
+// round-tripping want through JSON too, so an int literal like 42 compares
+// equal to the float64 that encoding/json decodes JSON numbers into.
+func jsonEqual(got, want interface{}) bool {
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		return reflect.DeepEqual(got, want)
+	}
+	var normalizedWant interface{}
+	if err := json.Unmarshal(wantJSON, &normalizedWant); err != nil {
+		return reflect.DeepEqual(got, want)
+	}
+	return reflect.DeepEqual(got, normalizedWant)
+}
+
+func lookupPath(v interface{}, path string) (interface{}, error) {
+	for _, seg := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			val, ok := node[seg]
+			if !ok {
+				return nil, fmt.Errorf("no key %q", seg)
+			}
+			v = val
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("no index %q", seg)
+			}
+			v = node[idx]
+		default:
+			return nil, fmt.Errorf("cannot index %T with %q", v, seg)
+		}
+	}
+	return v, nil
+}
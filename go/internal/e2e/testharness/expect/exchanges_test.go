@@ -0,0 +1,61 @@
+package expect
+
+import (
+	"fmt"
+	"testing"
+)
+
+// fakeT captures Fatalf calls instead of aborting the goroutine, so these
+// tests can assert on both success and failure paths.
+type fakeT struct {
+	testing.TB
+	failed  bool
+	message string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.failed = true
+	f.message = fmt.Sprintf(format, args...)
+}
+
+func TestToolCallSearchesResponseBody(t *testing.T) {
+	exchange := Exchange{
+		RequestBody:  []byte(`{"messages":[{"role":"user"}]}`),
+		ResponseBody: []byte("data: {\"type\":\"tool_call\",\"name\":\"edit_file\",\"arguments\":{\"path\":\"main.go\",\"line_count\":42}}\n\ndata: [DONE]\n\n"),
+	}
+
+	ft := &fakeT{}
+	On(ft, []Exchange{exchange}).NthRequest(0).ToolCall("edit_file").ArgEquals("path", "main.go").ArgEquals("line_count", 42)
+
+	if ft.failed {
+		t.Fatalf("expected ToolCall/ArgEquals to succeed, got: %s", ft.message)
+	}
+}
+
+func TestArgEqualsNormalizesJSONNumbers(t *testing.T) {
+	exchange := Exchange{
+		ResponseBody: []byte(`{"name":"edit_file","arguments":{"line_count":42}}`),
+	}
+
+	ft := &fakeT{}
+	On(ft, []Exchange{exchange}).NthRequest(0).ToolCall("edit_file").ArgEquals("line_count", 42)
+
+	if ft.failed {
+		t.Fatalf("expected int 42 to compare equal to JSON number 42, got: %s", ft.message)
+	}
+}
+
+func TestBodyJSONNormalizesJSONNumbers(t *testing.T) {
+	exchange := Exchange{
+		RequestBody: []byte(`{"count": 7}`),
+	}
+
+	ft := &fakeT{}
+	On(ft, []Exchange{exchange}).NthRequest(0).BodyJSON("count", 7)
+
+	if ft.failed {
+		t.Fatalf("expected int 7 to compare equal to JSON number 7, got: %s", ft.message)
+	}
+}
@@ -0,0 +1,41 @@
+package expect
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SSEEvents decodes the response body as Server-Sent Events, returning each
+// "data:" frame's JSON payload in order. The terminal "[DONE]" sentinel
+// frame used by the Copilot streaming API is skipped.
+func (a *ExchangeAssertion) SSEEvents() []interface{} {
+	a.t.Helper()
+	events, err := decodeSSE(a.exchange.ResponseBody)
+	if err != nil {
+		a.t.Fatalf("decoding SSE response body: %v", err)
+	}
+	return events
+}
+
+func decodeSSE(body []byte) ([]interface{}, error) {
+	var events []interface{}
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+
+		var event interface{}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("decoding frame %q: %w", data, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
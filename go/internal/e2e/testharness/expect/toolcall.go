@@ -0,0 +1,70 @@
+package expect
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// ToolCallAssertion asserts properties of a single tool call's arguments.
+type ToolCallAssertion struct {
+	t    testing.TB
+	name string
+	args map[string]interface{}
+}
+
+// ArgEquals asserts that the tool call's argument named key equals want.
+func (a *ToolCallAssertion) ArgEquals(key string, want interface{}) *ToolCallAssertion {
+	a.t.Helper()
+	got, ok := a.args[key]
+	if !ok {
+		a.t.Fatalf("tool call %q has no argument %q", a.name, key)
+	}
+	if !jsonEqual(got, want) {
+		a.t.Fatalf("tool call %q argument %q: expected %v, got %v", a.name, key, want, got)
+	}
+	return a
+}
+
+// findToolCallArgs walks a decoded JSON body looking for an object whose
+// "name" field equals name, and returns its parsed "arguments". The
+// Copilot tool_call envelope nests these arbitrarily deep depending on the
+// endpoint, so the search is recursive rather than tied to one shape.
+func findToolCallArgs(v interface{}, name string) (map[string]interface{}, bool) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		if n, ok := node["name"].(string); ok && n == name {
+			if args, ok := parseArguments(node["arguments"]); ok {
+				return args, true
+			}
+		}
+		for _, val := range node {
+			if args, ok := findToolCallArgs(val, name); ok {
+				return args, true
+			}
+		}
+	case []interface{}:
+		for _, item := range node {
+			if args, ok := findToolCallArgs(item, name); ok {
+				return args, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// parseArguments accepts either an already-decoded object or a JSON-encoded
+// string, matching how "arguments" is represented across Copilot endpoints.
+func parseArguments(raw interface{}) (map[string]interface{}, bool) {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		return v, true
+	case string:
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(v), &parsed); err != nil {
+			return nil, false
+		}
+		return parsed, true
+	default:
+		return nil, false
+	}
+}
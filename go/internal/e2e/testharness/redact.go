@@ -0,0 +1,264 @@
+package testharness
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redactor mutates a captured exchange in place, rewriting volatile fields
+// to stable placeholders before the exchange is written to or compared
+// against a snapshot. Redactors registered via TestContext.AddRedactor run
+// after the built-in ones, in registration order.
+type Redactor func(*ParsedHttpExchange)
+
+var rfc3339Pattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?Z`)
+
+// redactedHeaders lists headers that vary between runs and would otherwise
+// cause snapshot churn.
+var redactedHeaders = []string{"Authorization", "X-Request-Id", "X-Github-Token"}
+
+// volatileBodyFields lists JSON field names that carry ephemeral request IDs
+// or bearer tokens when they show up inside a request/response body (rather
+// than a header), e.g. a Copilot response embedding its own "request_id".
+var volatileBodyFields = map[string]bool{
+	"request_id":   true,
+	"requestId":    true,
+	"token":        true,
+	"access_token": true,
+}
+
+func redactTimestamps(e *ParsedHttpExchange) {
+	e.RequestBody = rfc3339Pattern.ReplaceAll(e.RequestBody, []byte("<TIMESTAMP>"))
+	e.ResponseBody = rfc3339Pattern.ReplaceAll(e.ResponseBody, []byte("<TIMESTAMP>"))
+}
+
+func redactHeaders(e *ParsedHttpExchange) {
+	for _, h := range redactedHeaders {
+		if e.Headers.Get(h) != "" {
+			e.Headers.Set(h, "<REDACTED>")
+		}
+	}
+}
+
+// redactBodyFields rewrites volatileBodyFields wherever they appear, at any
+// depth, in the request/response bodies. Bodies that aren't valid JSON are
+// left untouched.
+func redactBodyFields(e *ParsedHttpExchange) {
+	e.RequestBody = redactJSONFields(e.RequestBody)
+	e.ResponseBody = redactJSONFields(e.ResponseBody)
+}
+
+func redactJSONFields(body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	redactJSONValue(v)
+	redacted, err := marshalJSONNoEscape(v, "")
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func redactJSONValue(v interface{}) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, val := range node {
+			if volatileBodyFields[k] {
+				node[k] = "<REDACTED>"
+				continue
+			}
+			redactJSONValue(val)
+		}
+	case []interface{}:
+		for _, item := range node {
+			redactJSONValue(item)
+		}
+	}
+}
+
+// redactPath returns a Redactor that replaces occurrences of path (the
+// test's WorkDir or HomeDir) in the request/response bodies with
+// placeholder, so snapshots don't churn across machines or runs.
+func redactPath(path, placeholder string) Redactor {
+	return func(e *ParsedHttpExchange) {
+		if path == "" {
+			return
+		}
+		e.RequestBody = bytes.ReplaceAll(e.RequestBody, []byte(path), []byte(placeholder))
+		e.ResponseBody = bytes.ReplaceAll(e.ResponseBody, []byte(path), []byte(placeholder))
+	}
+}
+
+func defaultRedactors(workDir, homeDir string) []Redactor {
+	return []Redactor{
+		redactTimestamps,
+		redactHeaders,
+		redactBodyFields,
+		redactPath(workDir, "<WorkDir>"),
+		redactPath(homeDir, "<HomeDir>"),
+	}
+}
+
+func applyRedactors(e *ParsedHttpExchange, redactors []Redactor) {
+	for _, r := range redactors {
+		r(e)
+	}
+}
+
+// diffExchanges renders a unified diff between the recorded and live
+// exchange's headers and body, JSON-pretty-printing bodies that parse as
+// JSON. recorded is nil when the snapshot has no corresponding entry.
+func diffExchanges(recorded, live *ParsedHttpExchange) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- recorded %s\n+++ live     %s\n", describe(recorded), describe(live))
+	b.WriteString("@@ headers @@\n")
+	b.WriteString(unifiedLineDiff(formatHeaders(recorded), formatHeaders(live)))
+	b.WriteString("@@ body @@\n")
+	b.WriteString(unifiedLineDiff(prettyJSON(bodyOf(recorded)), prettyJSON(live.RequestBody)))
+	return b.String()
+}
+
+func describe(e *ParsedHttpExchange) string {
+	if e == nil {
+		return "<none>"
+	}
+	return fmt.Sprintf("%s %s", e.Method, e.Path)
+}
+
+func bodyOf(e *ParsedHttpExchange) []byte {
+	if e == nil {
+		return nil
+	}
+	return e.RequestBody
+}
+
+func formatHeaders(e *ParsedHttpExchange) string {
+	if e == nil || e.Headers == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(e.Headers))
+	for k := range e.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", k, strings.Join(e.Headers[k], ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func prettyJSON(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	pretty, err := marshalJSONNoEscape(v, "  ")
+	if err != nil {
+		return string(body)
+	}
+	return string(pretty)
+}
+
+// marshalJSONNoEscape marshals v to JSON without HTML-escaping '<', '>', and
+// '&' (unlike json.Marshal/json.MarshalIndent's default behavior), indenting
+// with indent when non-empty. Shared by prettyJSON and redactJSONFields so
+// snapshot bodies, diffs, and redaction placeholders all render literally
+// instead of as JSON unicode escape sequences.
+func marshalJSONNoEscape(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// unifiedLineDiff renders a minimal unified diff between two strings,
+// aligned on their longest common subsequence of lines.
+func unifiedLineDiff(a, b string) string {
+	if a == b {
+		return ""
+	}
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+	common := lcs(aLines, bLines)
+
+	var out strings.Builder
+	i, j, k := 0, 0, 0
+	for k < len(common) {
+		for i < len(aLines) && aLines[i] != common[k] {
+			fmt.Fprintf(&out, "-%s\n", aLines[i])
+			i++
+		}
+		for j < len(bLines) && bLines[j] != common[k] {
+			fmt.Fprintf(&out, "+%s\n", bLines[j])
+			j++
+		}
+		fmt.Fprintf(&out, " %s\n", common[k])
+		i++
+		j++
+		k++
+	}
+	for ; i < len(aLines); i++ {
+		fmt.Fprintf(&out, "-%s\n", aLines[i])
+	}
+	for ; j < len(bLines); j++ {
+		fmt.Fprintf(&out, "+%s\n", bLines[j])
+	}
+	return out.String()
+}
+
+// lcs returns the longest common subsequence of lines between a and b.
+func lcs(a, b []string) []string {
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				dp[i][j] = dp[i+1][j+1] + 1
+			case dp[i+1][j] >= dp[i][j+1]:
+				dp[i][j] = dp[i+1][j]
+			default:
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, a[i])
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
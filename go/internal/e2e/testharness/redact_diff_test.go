@@ -0,0 +1,44 @@
+package testharness
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffExchangesReportsBodyDifference(t *testing.T) {
+	recorded := &ParsedHttpExchange{
+		Method:      "POST",
+		Path:        "/chat/completions",
+		RequestBody: []byte(`{"prompt":"hello"}`),
+	}
+	live := &ParsedHttpExchange{
+		Method:      "POST",
+		Path:        "/chat/completions",
+		RequestBody: []byte(`{"prompt":"goodbye"}`),
+	}
+
+	diff := diffExchanges(recorded, live)
+
+	if !containsAll(diff, `-  "prompt": "hello"`, `+  "prompt": "goodbye"`) {
+		t.Fatalf("expected diff to call out the changed prompt field, got:\n%s", diff)
+	}
+}
+
+func TestDiffExchangesHandlesMissingRecorded(t *testing.T) {
+	live := &ParsedHttpExchange{Method: "GET", Path: "/models"}
+
+	diff := diffExchanges(nil, live)
+
+	if !containsAll(diff, "<none>", "GET /models") {
+		t.Fatalf("expected diff to note there was no recorded exchange, got:\n%s", diff)
+	}
+}
+
+func containsAll(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if !strings.Contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
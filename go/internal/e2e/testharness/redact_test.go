@@ -0,0 +1,25 @@
+package testharness
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactBodyFieldsStripsRequestIDsAndTokens(t *testing.T) {
+	exchange := &ParsedHttpExchange{
+		Headers:      http.Header{},
+		RequestBody:  []byte(`{"prompt":"hi"}`),
+		ResponseBody: []byte(`{"request_id":"abc-123","choices":[{"token":"secret"}]}`),
+	}
+
+	redactBodyFields(exchange)
+
+	body := string(exchange.ResponseBody)
+	if strings.Contains(body, "abc-123") || strings.Contains(body, "secret") {
+		t.Fatalf("expected request_id and token to be redacted, got: %s", body)
+	}
+	if !strings.Contains(body, "<REDACTED>") {
+		t.Fatalf("expected a <REDACTED> placeholder, got: %s", body)
+	}
+}
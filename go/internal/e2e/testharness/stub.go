@@ -0,0 +1,150 @@
+package testharness
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StubMatcher decides whether a StubRule applies to a request's body.
+type StubMatcher func(requestBody []byte) bool
+
+// Contains returns a StubMatcher matching requests whose body contains substr.
+func Contains(substr string) StubMatcher {
+	return func(body []byte) bool { return bytes.Contains(body, []byte(substr)) }
+}
+
+// Stub lets a test script the proxy's responses programmatically instead of
+// loading them from a recorded snapshot. Obtain one via TestContext.Stub().
+type Stub struct {
+	proxy *CapiProxy
+}
+
+// OnPrompt registers a rule that applies to requests whose body matches
+// matcher, and returns a builder for scripting its response.
+func (s *Stub) OnPrompt(matcher StubMatcher) *StubRule {
+	rule := &StubRule{matcher: matcher}
+	s.proxy.mu.Lock()
+	s.proxy.stubs = append(s.proxy.stubs, rule)
+	s.proxy.mu.Unlock()
+	return rule
+}
+
+// StubRule scripts the sequence of SSE events served for requests matching
+// its matcher, and supports scripting failure scenarios that are painful to
+// capture from the real service.
+type StubRule struct {
+	matcher        StubMatcher
+	status         int
+	events         []interface{}
+	dropAfter      int
+	disconnect     bool
+	malformedFrame bool
+}
+
+// RespondWithText appends a text chunk to the scripted response stream.
+func (r *StubRule) RespondWithText(text string) *StubRule {
+	r.events = append(r.events, map[string]interface{}{"type": "text", "content": text})
+	return r
+}
+
+// ThenToolCall appends a tool call event to the scripted response stream.
+func (r *StubRule) ThenToolCall(name string, args map[string]interface{}) *StubRule {
+	r.events = append(r.events, map[string]interface{}{
+		"type":      "tool_call",
+		"name":      name,
+		"arguments": args,
+	})
+	return r
+}
+
+// ThenFinish appends the terminal "[DONE]" sentinel frame.
+func (r *StubRule) ThenFinish() *StubRule {
+	r.events = append(r.events, "[DONE]")
+	return r
+}
+
+// RespondWithRateLimit scripts a 429 response instead of a streamed body,
+// for exercising retry/backoff behavior.
+func (r *StubRule) RespondWithRateLimit() *StubRule {
+	r.status = http.StatusTooManyRequests
+	return r
+}
+
+// ThenMalformedFrame appends an SSE frame that isn't valid JSON, for
+// exercising the CLI's handling of a corrupt stream.
+func (r *StubRule) ThenMalformedFrame() *StubRule {
+	r.events = append(r.events, "{not valid json")
+	return r
+}
+
+// ThenDisconnect closes the underlying TCP connection after the events
+// scripted so far, simulating a mid-stream disconnect rather than a clean
+// end of stream.
+func (r *StubRule) ThenDisconnect() *StubRule {
+	r.dropAfter = len(r.events)
+	r.disconnect = true
+	return r
+}
+
+func (p *CapiProxy) findStub(body []byte) *StubRule {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, rule := range p.stubs {
+		if rule.matcher(body) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (p *CapiProxy) serveStub(w http.ResponseWriter, rule *StubRule, exchange *ParsedHttpExchange) {
+	if rule.status != 0 {
+		http.Error(w, http.StatusText(rule.status), rule.status)
+		exchange.StatusCode = rule.status
+		exchange.ResponseBody = []byte(http.StatusText(rule.status) + "\n")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	var body bytes.Buffer
+	for i, event := range rule.events {
+		if rule.disconnect && i >= rule.dropAfter {
+			break
+		}
+		frame, ok := event.(string)
+		if !ok {
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			frame = string(data)
+		}
+		fmt.Fprintf(w, "data: %s\n\n", frame)
+		fmt.Fprintf(&body, "data: %s\n\n", frame)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	exchange.StatusCode = http.StatusOK
+	exchange.ResponseBody = body.Bytes()
+
+	if rule.disconnect {
+		// Hijack and close the raw connection instead of returning normally,
+		// so the client observes an actual dropped connection mid-stream
+		// rather than a clean end of a truncated-but-complete response.
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			return
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,95 @@
+package testharness
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStubTakesPrecedenceOverSnapshotLookup(t *testing.T) {
+	proxy := NewCapiProxy()
+	proxy.SetMode(ModeReplay) // no snapshot loaded: would 404 without a matching stub
+
+	(&Stub{proxy: proxy}).OnPrompt(Contains("refactor")).
+		RespondWithText("sure, refactoring now").
+		ThenToolCall("edit_file", map[string]interface{}{"path": "main.go"}).
+		ThenFinish()
+
+	req := httptest.NewRequest("POST", "/chat/completions", strings.NewReader(`{"prompt":"please refactor this"}`))
+	rec := httptest.NewRecorder()
+
+	proxy.handle(rec, req)
+
+	if rec.Code != 200 {
+		t.Fatalf("expected the stub to serve a 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "edit_file") {
+		t.Fatalf("expected the scripted tool call to appear in the response, got: %s", rec.Body.String())
+	}
+
+	exchanges, err := proxy.GetExchanges()
+	if err != nil {
+		t.Fatalf("GetExchanges: %v", err)
+	}
+	if len(exchanges) != 1 || exchanges[0].StatusCode != 200 {
+		t.Fatalf("expected one captured 200 exchange, got: %+v", exchanges)
+	}
+}
+
+func TestRespondWithRateLimitCapturesResponseBody(t *testing.T) {
+	proxy := NewCapiProxy()
+	proxy.SetMode(ModeReplay)
+
+	(&Stub{proxy: proxy}).OnPrompt(Contains("please")).RespondWithRateLimit()
+
+	req := httptest.NewRequest("POST", "/chat/completions", strings.NewReader(`{"prompt":"please"}`))
+	rec := httptest.NewRecorder()
+
+	proxy.handle(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a 429, got %d", rec.Code)
+	}
+
+	exchanges, err := proxy.GetExchanges()
+	if err != nil {
+		t.Fatalf("GetExchanges: %v", err)
+	}
+	if len(exchanges) != 1 {
+		t.Fatalf("expected one captured exchange, got: %+v", exchanges)
+	}
+	if exchanges[0].StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the captured exchange to record the 429, got: %+v", exchanges[0])
+	}
+	if len(exchanges[0].ResponseBody) == 0 {
+		t.Fatalf("expected the captured exchange to record the rate-limit response body, got an empty body")
+	}
+}
+
+func TestThenDisconnectClosesTheConnectionMidStream(t *testing.T) {
+	proxy := NewCapiProxy()
+	proxy.SetMode(ModeReplay)
+
+	(&Stub{proxy: proxy}).OnPrompt(Contains("refactor")).
+		RespondWithText("partial reply").
+		ThenDisconnect()
+
+	url, err := proxy.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer proxy.StopWithOptions(false)
+
+	resp, err := http.Post(url+"/chat/completions", "application/json", strings.NewReader(`{"prompt":"please refactor this"}`))
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	defer resp.Body.Close()
+
+	_, err = io.ReadAll(resp.Body)
+	if err == nil {
+		t.Fatalf("expected reading the response body to fail after a mid-stream disconnect, got no error")
+	}
+}